@@ -0,0 +1,194 @@
+package binary
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// littleEndianHost reports whether the process is running on a
+// little-endian architecture. The fast path below copies struct memory
+// directly, which is only safe to treat as tinybit's wire format because
+// that format is little-endian for every field kind the fast path accepts.
+var littleEndianHost = func() bool {
+	var x uint16 = 1
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// plainInfo is the cached result of analyzing a type for plain-layout
+// eligibility; a nil entry in plainCache means the type was checked and
+// found not to be plain.
+type plainInfo struct {
+	size int
+}
+
+var (
+	plainCacheMu sync.RWMutex
+	plainCache   = map[reflect.Type]*plainInfo{}
+)
+
+// marshalerType and unmarshalerType let plainLayout check, the same way
+// Marshal's type switch and Unmarshal's interface check already do, whether
+// a type supplies its own wire encoding.
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+)
+
+// hasCustomCodec reports whether t or *t implements Marshaler or
+// Unmarshaler. Such types (binary.VarInt, binary.VarStr, ...) must never
+// be treated as plain: Marshal/Unmarshal dispatch to their methods instead
+// of the default reflect-based encoding, and the fast path's raw memory
+// copy would silently bypass that encoding.
+func hasCustomCodec(t reflect.Type) bool {
+	return t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) ||
+		t.Implements(unmarshalerType) || reflect.PointerTo(t).Implements(unmarshalerType)
+}
+
+// plainLayout reports whether t is a "plain" type: some combination of
+// fixed-width primitives that this package already encodes as
+// little-endian (everything Marshal handles by default except uint16,
+// which is big-endian on the wire), fixed arrays of those, and nested
+// plain structs, laid out by the Go compiler with no padding between
+// fields in their declared (== wire) order, no bin tags, and no custom
+// Marshaler/Unmarshaler methods anywhere in the type (see hasCustomCodec).
+// Such types can be read or written with a single memory copy instead of a
+// field-by-field reflect walk. It returns the type's fixed wire size and
+// true when so.
+func plainLayout(t reflect.Type) (int, bool) {
+	if !littleEndianHost {
+		return 0, false
+	}
+
+	plainCacheMu.RLock()
+	info, ok := plainCache[t]
+	plainCacheMu.RUnlock()
+	if ok {
+		if info == nil {
+			return 0, false
+		}
+		return info.size, true
+	}
+
+	size, ok := computePlainLayout(t)
+
+	plainCacheMu.Lock()
+	if ok {
+		plainCache[t] = &plainInfo{size: size}
+	} else {
+		plainCache[t] = nil
+	}
+	plainCacheMu.Unlock()
+
+	return size, ok
+}
+
+func computePlainLayout(t reflect.Type) (int, bool) {
+	if hasCustomCodec(t) {
+		return 0, false
+	}
+
+	switch t.Kind() {
+	// int8 is deliberately excluded: Marshal's type switch has no case for
+	// bare int8, only uint8, so treating it as plain here would make
+	// Size/MarshalTo accept types Marshal itself rejects.
+	case reflect.Bool, reflect.Uint8:
+		return 1, true
+
+	case reflect.Int32, reflect.Uint32:
+		return 4, true
+
+	case reflect.Int64, reflect.Uint64:
+		return 8, true
+
+	case reflect.Array:
+		elemSize, ok := computePlainLayout(t.Elem())
+		if !ok {
+			return 0, false
+		}
+		return elemSize * t.Len(), true
+
+	case reflect.Struct:
+		tags, err := structTags(t)
+		if err != nil {
+			return 0, false
+		}
+
+		offset := 0
+		for i := 0; i < t.NumField(); i++ {
+			if tags[i] != (fieldTag{}) {
+				return 0, false
+			}
+
+			f := t.Field(i)
+			if int(f.Offset) != offset {
+				return 0, false // compiler-inserted padding
+			}
+
+			size, ok := computePlainLayout(f.Type)
+			if !ok {
+				return 0, false
+			}
+
+			offset += size
+		}
+
+		if int(t.Size()) != offset {
+			return 0, false // trailing padding
+		}
+
+		return offset, true
+
+	default:
+		return 0, false
+	}
+}
+
+// Size returns the number of bytes v would occupy when marshaled, and
+// whether v is plain per plainLayout — the only case MarshalTo can serve
+// without falling back to Marshal.
+func Size(v interface{}) (int, bool) {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return plainLayout(t)
+}
+
+// MarshalTo encodes v into dst and returns the number of bytes written.
+// When v's type is plain (see Size), this is a single, zero-allocation
+// memory copy; passing a pointer avoids an extra copy to make the value
+// addressable. Everything else falls back to Marshal, which does
+// allocate.
+func MarshalTo(dst []byte, v interface{}) (int, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+
+	if size, ok := plainLayout(rv.Type()); ok {
+		if len(dst) < size {
+			return 0, fmt.Errorf("binary: MarshalTo: dst too small: need %d, have %d", size, len(dst))
+		}
+
+		if !rv.CanAddr() {
+			addressable := reflect.New(rv.Type()).Elem()
+			addressable.Set(rv)
+			rv = addressable
+		}
+
+		src := unsafe.Slice((*byte)(unsafe.Pointer(rv.UnsafeAddr())), size)
+		copy(dst, src)
+
+		return size, nil
+	}
+
+	b, err := Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+
+	return copy(dst, b), nil
+}
@@ -0,0 +1,82 @@
+package binary
+
+import (
+	"bytes"
+	"testing"
+)
+
+type plainPOD struct {
+	A uint32
+	C [4]byte
+	B uint64
+}
+
+func TestSizePlainStruct(t *testing.T) {
+	size, ok := Size(plainPOD{})
+	if !ok {
+		t.Fatal("expected plainPOD to be plain")
+	}
+	if size != 4+8+4 {
+		t.Fatalf("got size %d, want %d", size, 4+8+4)
+	}
+}
+
+func TestMarshalToPlainStruct(t *testing.T) {
+	v := plainPOD{A: 1, B: 2, C: [4]byte{9, 9, 9, 9}}
+
+	want, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	got := make([]byte, len(want))
+	n, err := MarshalTo(got, v)
+	if err != nil {
+		t.Fatalf("MarshalTo: %v", err)
+	}
+	if n != len(want) {
+		t.Fatalf("MarshalTo wrote %d bytes, want %d", n, len(want))
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("MarshalTo = %x, want %x (from Marshal)", got, want)
+	}
+}
+
+// A type implementing Marshaler/Unmarshaler must never be treated as
+// plain, even though its underlying Kind (uint64) looks fixed-width: its
+// wire encoding is variable-length and nothing like a raw memory copy.
+func TestSizeRejectsCustomCodec(t *testing.T) {
+	if _, ok := Size(VarInt(5)); ok {
+		t.Fatal("Size reported VarInt as plain, but VarInt implements Marshaler/Unmarshaler")
+	}
+}
+
+func TestMarshalToMatchesMarshalForVarInt(t *testing.T) {
+	want, err := Marshal(VarInt(5))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	dst := make([]byte, 16)
+	n, err := MarshalTo(dst, VarInt(5))
+	if err != nil {
+		t.Fatalf("MarshalTo: %v", err)
+	}
+
+	if got := dst[:n]; !bytes.Equal(got, want) {
+		t.Fatalf("MarshalTo(VarInt(5)) = %x, want %x (matching Marshal's VarInt encoding)", got, want)
+	}
+}
+
+// A struct with a custom-codec field must also fall back, since
+// computePlainLayout recurses into struct fields.
+func TestSizeRejectsStructWithCustomCodecField(t *testing.T) {
+	type withVarInt struct {
+		Count VarInt
+		Flags uint32
+	}
+
+	if _, ok := Size(withVarInt{}); ok {
+		t.Fatal("Size reported a struct containing a VarInt field as plain")
+	}
+}
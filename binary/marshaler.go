@@ -17,7 +17,10 @@ type Marshaler interface {
 	MarshalBinary() ([]byte, error)
 }
 
-// Marshal returns the binary encoding of v.
+// Marshal returns the binary encoding of v. Struct fields are encoded using
+// Go's default size/endianness for their type unless tagged otherwise with a
+// `bin:"..."` struct tag, e.g. `bin:"be"`, `bin:"varint"`, `bin:"varstr"` or
+// `bin:"fixed=12"` (see tags.go).
 func Marshal(v interface{}) ([]byte, error) {
 	var buf bytes.Buffer
 
@@ -107,12 +110,17 @@ func Marshal(v interface{}) ([]byte, error) {
 func marshalStruct(v interface{}) ([]byte, error) {
 	var buf bytes.Buffer
 	vv := reflect.ValueOf(v)
+	t := vv.Type()
+
+	tags, err := structTags(t)
+	if err != nil {
+		return nil, err
+	}
 
 	for i := 0; i < vv.NumField(); i++ {
-		s, err := Marshal(reflect.Indirect(vv.Field(i)).Interface())
+		s, err := marshalField(reflect.Indirect(vv.Field(i)), tags[i])
 		if err != nil {
-			f := reflect.TypeOf(v).Field(i).Name
-			return nil, fmt.Errorf("failed to marshal field %s: %v", f, err)
+			return nil, fmt.Errorf("failed to marshal field %s: %v", t.Field(i).Name, err)
 		}
 
 		if _, err := buf.Write(s); err != nil {
@@ -121,4 +129,112 @@ func marshalStruct(v interface{}) ([]byte, error) {
 	}
 
 	return buf.Bytes(), nil
-}
\ No newline at end of file
+}
+
+// marshalField encodes a single struct field according to its parsed bin
+// tag, falling back to Marshal's default type-based encoding for fields
+// with no tag (or a bare "le"/"be" tag, which only overrides endianness).
+func marshalField(fv reflect.Value, tag fieldTag) ([]byte, error) {
+	switch tag.kind {
+	case tagVarint:
+		return marshalVarInt(fv)
+	case tagVarstr:
+		return marshalVarStr(fv)
+	case tagFixed:
+		return marshalFixedField(fv, tag.fixed)
+	}
+
+	if tag.length > 0 {
+		return marshalFixedField(fv, tag.length)
+	}
+
+	if tag.endian != nil {
+		var buf bytes.Buffer
+		if err := binary.Write(&buf, tag.endian, fv.Interface()); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	b, err := Marshal(fv.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	if tag.size > 0 {
+		return padOrTruncate(b, tag.size), nil
+	}
+
+	return b, nil
+}
+
+// varIntBytes encodes n using Bitcoin's VarInt prefix scheme: values below
+// 0xfd are written as a single raw byte, larger values get a 0xfd/0xfe/0xff
+// prefix byte followed by a 2/4/8-byte little-endian integer.
+func varIntBytes(n uint64) []byte {
+	switch {
+	case n < 0xfd:
+		return []byte{byte(n)}
+	case n <= 0xffff:
+		b := make([]byte, 3)
+		b[0] = 0xfd
+		binary.LittleEndian.PutUint16(b[1:], uint16(n))
+		return b
+	case n <= 0xffffffff:
+		b := make([]byte, 5)
+		b[0] = 0xfe
+		binary.LittleEndian.PutUint32(b[1:], uint32(n))
+		return b
+	default:
+		b := make([]byte, 9)
+		b[0] = 0xff
+		binary.LittleEndian.PutUint64(b[1:], n)
+		return b
+	}
+}
+
+func marshalVarInt(fv reflect.Value) ([]byte, error) {
+	switch fv.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return varIntBytes(fv.Uint()), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		return varIntBytes(uint64(fv.Int())), nil
+	default:
+		return nil, fmt.Errorf("varint tag on non-integer field of kind %s", fv.Kind())
+	}
+}
+
+func marshalVarStr(fv reflect.Value) ([]byte, error) {
+	if fv.Kind() != reflect.String {
+		return nil, fmt.Errorf("varstr tag on non-string field of kind %s", fv.Kind())
+	}
+
+	s := fv.String()
+
+	var buf bytes.Buffer
+	buf.Write(varIntBytes(uint64(len(s))))
+	buf.WriteString(s)
+
+	return buf.Bytes(), nil
+}
+
+// marshalFixedField encodes a string or []byte field as exactly n bytes,
+// zero-padding it on the right (used for e.g. the 12-byte, NUL-padded
+// command string in a p2p message header).
+func marshalFixedField(fv reflect.Value, n int) ([]byte, error) {
+	var raw []byte
+
+	switch fv.Kind() {
+	case reflect.String:
+		raw = []byte(fv.String())
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.Uint8 {
+			return nil, fmt.Errorf("fixed/len tag on unsupported slice type %s", fv.Type())
+		}
+		raw = fv.Bytes()
+	default:
+		return nil, fmt.Errorf("fixed/len tag on unsupported field of kind %s", fv.Kind())
+	}
+
+	return padOrTruncate(raw, n), nil
+}
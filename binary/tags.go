@@ -0,0 +1,119 @@
+package binary
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// tagKind describes the wire encoding requested for a struct field via its
+// `bin:"..."` tag.
+type tagKind int
+
+const (
+	tagPlain tagKind = iota
+	tagVarint
+	tagVarstr
+	tagFixed
+)
+
+// fieldTag is the parsed form of a field's `bin:"..."` struct tag. The zero
+// value means "no tag", i.e. fall back to Marshal's default Go-type-based
+// encoding.
+type fieldTag struct {
+	kind   tagKind
+	endian binary.ByteOrder
+	fixed  int // fixed=N: zero-padded, fixed-width string/byte field
+	length int // len=N: fixed-width string/byte field, same encoding as fixed
+	size   int // size=N: pad/truncate the default encoding to N bytes
+}
+
+var (
+	tagCacheMu sync.RWMutex
+	tagCache   = map[reflect.Type][]fieldTag{}
+)
+
+// structTags returns the parsed bin tag for every field of t, in field
+// order. Results are cached per type since a type's tags never change.
+func structTags(t reflect.Type) ([]fieldTag, error) {
+	tagCacheMu.RLock()
+	tags, ok := tagCache[t]
+	tagCacheMu.RUnlock()
+	if ok {
+		return tags, nil
+	}
+
+	tags = make([]fieldTag, t.NumField())
+	for i := range tags {
+		ft, err := parseTag(t.Field(i).Tag.Get("bin"))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", t.Field(i).Name, err)
+		}
+		tags[i] = ft
+	}
+
+	tagCacheMu.Lock()
+	tagCache[t] = tags
+	tagCacheMu.Unlock()
+
+	return tags, nil
+}
+
+// parseTag parses a single `bin:"..."` tag value, e.g. "le", "varstr" or
+// "fixed=12".
+func parseTag(tag string) (fieldTag, error) {
+	var ft fieldTag
+
+	if tag == "" {
+		return ft, nil
+	}
+
+	for _, part := range strings.Split(tag, ",") {
+		key, val, _ := strings.Cut(part, "=")
+
+		switch key {
+		case "le":
+			ft.endian = binary.LittleEndian
+		case "be":
+			ft.endian = binary.BigEndian
+		case "varint":
+			ft.kind = tagVarint
+		case "varstr":
+			ft.kind = tagVarstr
+		case "fixed":
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return ft, fmt.Errorf("invalid fixed tag %q: %w", part, err)
+			}
+			ft.kind = tagFixed
+			ft.fixed = n
+		case "len":
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return ft, fmt.Errorf("invalid len tag %q: %w", part, err)
+			}
+			ft.length = n
+		case "size":
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return ft, fmt.Errorf("invalid size tag %q: %w", part, err)
+			}
+			ft.size = n
+		default:
+			return ft, fmt.Errorf("unknown bin tag %q", part)
+		}
+	}
+
+	return ft, nil
+}
+
+// padOrTruncate returns b resized to exactly n bytes, zero-padding on the
+// right if it is short and dropping trailing bytes if it is long.
+func padOrTruncate(b []byte, n int) []byte {
+	out := make([]byte, n)
+	copy(out, b)
+	return out
+}
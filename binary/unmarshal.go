@@ -0,0 +1,345 @@
+package binary
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Unmarshaler is the interface implemented by types that can unmarshal a
+// binary representation of themselves, matching encoding.BinaryUnmarshaler.
+type Unmarshaler interface {
+	UnmarshalBinary(data []byte) error
+}
+
+// UnmarshalError describes a failure to decode a single struct field. It
+// identifies the struct type, field name and byte offset at which decoding
+// failed, so callers can tell exactly which part of a malformed peer
+// message was the problem instead of digging through a generic error chain.
+type UnmarshalError struct {
+	Type   string
+	Field  string
+	Offset int
+	Err    error
+}
+
+func (e *UnmarshalError) Error() string {
+	return fmt.Sprintf("binary: unmarshal %s.%s at offset %d: %v", e.Type, e.Field, e.Offset, e.Err)
+}
+
+func (e *UnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+// Unmarshal parses data into v, which must be a non-nil pointer. It walks
+// structs the same way marshalStruct does, using the same `bin:"..."` tags
+// to decide how each field is encoded on the wire. It does not decode
+// everything Marshal encodes: a bare "le"/"be" tag is only decoded on
+// uint16, uint32 and uint64 fields, pointer fields are rejected rather than
+// followed, and a type that implements Marshaler but not Unmarshaler is
+// decoded by its kind rather than by its own method.
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("binary: Unmarshal requires a non-nil pointer, got %T", v)
+	}
+
+	_, err := unmarshalValue(data, rv.Elem())
+	return err
+}
+
+// unmarshalValue decodes the front of data into rv and returns the number
+// of bytes consumed.
+func unmarshalValue(data []byte, rv reflect.Value) (int, error) {
+	if rv.CanAddr() {
+		addr := rv.Addr()
+		if u, ok := addr.Interface().(Unmarshaler); ok {
+			if err := u.UnmarshalBinary(data); err != nil {
+				return 0, err
+			}
+
+			// UnmarshalBinary has no way to report how many bytes of data
+			// it actually consumed, so re-marshal the now-populated value
+			// and use its length. Every Unmarshaler in this package also
+			// implements Marshaler and round-trips to the same byte count
+			// it read, so this is accurate for anything but a type that
+			// breaks that pairing.
+			m, ok := addr.Interface().(Marshaler)
+			if !ok {
+				return 0, fmt.Errorf("binary: %s implements Unmarshaler but not Marshaler, so Unmarshal cannot tell how many bytes it consumed", rv.Type())
+			}
+
+			b, err := m.MarshalBinary()
+			if err != nil {
+				return 0, err
+			}
+
+			return len(b), nil
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		if len(data) < 1 {
+			return 0, fmt.Errorf("unexpected end of data")
+		}
+		rv.SetBool(data[0] != 0)
+		return 1, nil
+
+	case reflect.Uint8:
+		if len(data) < 1 {
+			return 0, fmt.Errorf("unexpected end of data")
+		}
+		rv.SetUint(uint64(data[0]))
+		return 1, nil
+
+	// port
+	case reflect.Uint16:
+		if len(data) < 2 {
+			return 0, fmt.Errorf("unexpected end of data")
+		}
+		rv.SetUint(uint64(binary.BigEndian.Uint16(data)))
+		return 2, nil
+
+	case reflect.Int32:
+		if len(data) < 4 {
+			return 0, fmt.Errorf("unexpected end of data")
+		}
+		rv.SetInt(int64(int32(binary.LittleEndian.Uint32(data))))
+		return 4, nil
+
+	case reflect.Uint32:
+		if len(data) < 4 {
+			return 0, fmt.Errorf("unexpected end of data")
+		}
+		rv.SetUint(uint64(binary.LittleEndian.Uint32(data)))
+		return 4, nil
+
+	case reflect.Int64:
+		if len(data) < 8 {
+			return 0, fmt.Errorf("unexpected end of data")
+		}
+		rv.SetInt(int64(binary.LittleEndian.Uint64(data)))
+		return 8, nil
+
+	case reflect.Uint64:
+		if len(data) < 8 {
+			return 0, fmt.Errorf("unexpected end of data")
+		}
+		rv.SetUint(binary.LittleEndian.Uint64(data))
+		return 8, nil
+
+	case reflect.Array:
+		n := rv.Len()
+		if len(data) < n {
+			return 0, fmt.Errorf("unexpected end of data")
+		}
+		reflect.Copy(rv, reflect.ValueOf(data[:n]))
+		return n, nil
+
+	// An untagged string has no length prefix on the wire (Marshal just
+	// dumps its bytes raw, matching its pre-tags behavior), so there is no
+	// way to know where it ends other than "the rest of data" - only
+	// sensible as a struct's last field. Use `bin:"varstr"` or
+	// binary.VarStr for a real, self-delimiting Bitcoin VarStr.
+	case reflect.String:
+		rv.SetString(string(data))
+		return len(data), nil
+
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			return 0, fmt.Errorf("unsupported slice type %s", rv.Type())
+		}
+		rv.SetBytes(append([]byte(nil), data...))
+		return len(data), nil
+
+	case reflect.Struct:
+		return unmarshalStruct(data, rv)
+
+	default:
+		return 0, fmt.Errorf("unsupported type %s", rv.Type())
+	}
+}
+
+func unmarshalStruct(data []byte, rv reflect.Value) (int, error) {
+	t := rv.Type()
+
+	tags, err := structTags(t)
+	if err != nil {
+		return 0, err
+	}
+
+	offset := 0
+	for i := 0; i < rv.NumField(); i++ {
+		n, err := unmarshalField(data[offset:], rv.Field(i), tags[i])
+		if err != nil {
+			return 0, &UnmarshalError{
+				Type:   t.Name(),
+				Field:  t.Field(i).Name,
+				Offset: offset,
+				Err:    err,
+			}
+		}
+		offset += n
+	}
+
+	return offset, nil
+}
+
+// unmarshalField decodes a single struct field according to its parsed bin
+// tag, falling back to unmarshalValue's default type-based decoding for
+// fields with no tag (or a bare "le"/"be" tag, which only overrides
+// endianness).
+func unmarshalField(data []byte, rv reflect.Value, tag fieldTag) (int, error) {
+	switch tag.kind {
+	case tagVarint:
+		return unmarshalVarInt(data, rv)
+	case tagVarstr:
+		return unmarshalVarStr(data, rv)
+	case tagFixed:
+		return unmarshalFixedField(data, rv, tag.fixed)
+	}
+
+	if tag.length > 0 {
+		return unmarshalFixedField(data, rv, tag.length)
+	}
+
+	if tag.endian != nil {
+		return unmarshalEndian(data, rv, tag.endian)
+	}
+
+	if tag.size > 0 {
+		if len(data) < tag.size {
+			return 0, fmt.Errorf("unexpected end of data")
+		}
+		if _, err := unmarshalValue(data[:tag.size], rv); err != nil {
+			return 0, err
+		}
+		return tag.size, nil
+	}
+
+	return unmarshalValue(data, rv)
+}
+
+func unmarshalEndian(data []byte, rv reflect.Value, order binary.ByteOrder) (int, error) {
+	switch rv.Kind() {
+	case reflect.Uint16:
+		if len(data) < 2 {
+			return 0, fmt.Errorf("unexpected end of data")
+		}
+		rv.SetUint(uint64(order.Uint16(data)))
+		return 2, nil
+	case reflect.Uint32:
+		if len(data) < 4 {
+			return 0, fmt.Errorf("unexpected end of data")
+		}
+		rv.SetUint(uint64(order.Uint32(data)))
+		return 4, nil
+	case reflect.Uint64:
+		if len(data) < 8 {
+			return 0, fmt.Errorf("unexpected end of data")
+		}
+		rv.SetUint(order.Uint64(data))
+		return 8, nil
+	default:
+		return 0, fmt.Errorf("le/be tag on unsupported field of kind %s", rv.Kind())
+	}
+}
+
+// readVarInt decodes a Bitcoin VarInt from the front of data, returning its
+// value and the number of prefix+payload bytes consumed. A value written
+// with a longer prefix than it needs is rejected, so every accepted VarInt
+// re-encodes (via varIntBytes) to exactly the bytes it was read from.
+func readVarInt(data []byte) (uint64, int, error) {
+	if len(data) < 1 {
+		return 0, 0, fmt.Errorf("unexpected end of data")
+	}
+
+	var n uint64
+	var consumed int
+	switch data[0] {
+	case 0xfd:
+		if len(data) < 3 {
+			return 0, 0, fmt.Errorf("unexpected end of data")
+		}
+		n, consumed = uint64(binary.LittleEndian.Uint16(data[1:])), 3
+	case 0xfe:
+		if len(data) < 5 {
+			return 0, 0, fmt.Errorf("unexpected end of data")
+		}
+		n, consumed = uint64(binary.LittleEndian.Uint32(data[1:])), 5
+	case 0xff:
+		if len(data) < 9 {
+			return 0, 0, fmt.Errorf("unexpected end of data")
+		}
+		n, consumed = binary.LittleEndian.Uint64(data[1:]), 9
+	default:
+		return uint64(data[0]), 1, nil
+	}
+
+	if len(varIntBytes(n)) != consumed {
+		return 0, 0, fmt.Errorf("non-canonical varint encoding")
+	}
+	return n, consumed, nil
+}
+
+func unmarshalVarInt(data []byte, rv reflect.Value) (int, error) {
+	n, consumed, err := readVarInt(data)
+	if err != nil {
+		return 0, err
+	}
+
+	switch rv.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		rv.SetUint(n)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		rv.SetInt(int64(n))
+	default:
+		return 0, fmt.Errorf("varint tag on non-integer field of kind %s", rv.Kind())
+	}
+
+	return consumed, nil
+}
+
+func unmarshalVarStr(data []byte, rv reflect.Value) (int, error) {
+	if rv.Kind() != reflect.String {
+		return 0, fmt.Errorf("varstr tag on non-string field of kind %s", rv.Kind())
+	}
+
+	l, n, err := readVarInt(data)
+	if err != nil {
+		return 0, err
+	}
+
+	// Compare as uint64 before casting l to int: a malicious 0xff prefix
+	// can encode a length >= 2^63, which would wrap negative as an int and
+	// pass a naive len(data) < n+int(l) check, then panic on the slice
+	// below.
+	if l > uint64(len(data)-n) {
+		return 0, fmt.Errorf("unexpected end of data")
+	}
+
+	rv.SetString(string(data[n : n+int(l)]))
+	return n + int(l), nil
+}
+
+func unmarshalFixedField(data []byte, rv reflect.Value, n int) (int, error) {
+	if len(data) < n {
+		return 0, fmt.Errorf("unexpected end of data")
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(strings.TrimRight(string(data[:n]), "\x00"))
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			return 0, fmt.Errorf("fixed/len tag on unsupported slice type %s", rv.Type())
+		}
+		rv.SetBytes(append([]byte(nil), data[:n]...))
+	default:
+		return 0, fmt.Errorf("fixed/len tag on unsupported field of kind %s", rv.Kind())
+	}
+
+	return n, nil
+}
@@ -0,0 +1,136 @@
+package binary
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// VarInt is Bitcoin's variable-length integer encoding: values below 0xfd
+// are written as a single byte, larger values get a 0xfd/0xfe/0xff prefix
+// byte followed by a 2/4/8-byte little-endian integer. It implements
+// Marshaler/Unmarshaler itself, so a message struct can declare a field as
+// binary.VarInt instead of relying on the `bin:"varint"` tag.
+type VarInt uint64
+
+func (v VarInt) MarshalBinary() ([]byte, error) {
+	return varIntBytes(uint64(v)), nil
+}
+
+func (v *VarInt) UnmarshalBinary(data []byte) error {
+	n, _, err := readVarInt(data)
+	if err != nil {
+		return err
+	}
+
+	*v = VarInt(n)
+	return nil
+}
+
+// VarStr is Bitcoin's variable-length string encoding: a VarInt length
+// prefix followed by that many raw bytes. Unlike a plain string field
+// (which Marshal writes with no length prefix at all), VarStr round-trips
+// through Unmarshal correctly.
+type VarStr string
+
+func (s VarStr) MarshalBinary() ([]byte, error) {
+	return append(varIntBytes(uint64(len(s))), s...), nil
+}
+
+func (s *VarStr) UnmarshalBinary(data []byte) error {
+	l, n, err := readVarInt(data)
+	if err != nil {
+		return err
+	}
+
+	// Compare as uint64 before casting l to int: a malicious 0xff prefix
+	// can encode a length >= 2^63, which would wrap negative as an int and
+	// pass a naive len(data) < n+int(l) check, then panic on the slice
+	// below.
+	if l > uint64(len(data)-n) {
+		return fmt.Errorf("unexpected end of data")
+	}
+
+	*s = VarStr(data[n : n+int(l)])
+	return nil
+}
+
+// InvVect is a Bitcoin inventory vector, as used in the inv, getdata and
+// notfound messages: a 4-byte little-endian type code followed by a
+// 32-byte hash.
+type InvVect struct {
+	Type uint32
+	Hash [32]byte
+}
+
+func (v InvVect) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 4+len(v.Hash))
+	binary.LittleEndian.PutUint32(b, v.Type)
+	copy(b[4:], v.Hash[:])
+
+	return b, nil
+}
+
+func (v *InvVect) UnmarshalBinary(data []byte) error {
+	if len(data) < 4+len(v.Hash) {
+		return fmt.Errorf("unexpected end of data")
+	}
+
+	v.Type = binary.LittleEndian.Uint32(data)
+	copy(v.Hash[:], data[4:4+len(v.Hash)])
+
+	return nil
+}
+
+// VarSlice encodes a VarInt length prefix followed by the encoding of each
+// element, e.g. the inv/getdata messages' inventory list can be declared
+// as binary.VarSlice[InvVect] instead of a hand-rolled length-prefixed
+// loop.
+type VarSlice[T any] []T
+
+func (s VarSlice[T]) MarshalBinary() ([]byte, error) {
+	buf := varIntBytes(uint64(len(s)))
+
+	for i := range s {
+		b, err := Marshal(s[i])
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		buf = append(buf, b...)
+	}
+
+	return buf, nil
+}
+
+func (s *VarSlice[T]) UnmarshalBinary(data []byte) error {
+	l, n, err := readVarInt(data)
+	if err != nil {
+		return err
+	}
+
+	// l is an attacker-controlled count, and every element needs at least
+	// one byte on the wire, so cap it at what's actually left in data
+	// before allocating: trusting it directly into make panics with
+	// "makeslice: len out of range" (or worse, succeeds and hands an
+	// attacker a multi-GB allocation) for a handful of bytes claiming a
+	// huge element count.
+	if l > uint64(len(data)-n) {
+		return fmt.Errorf("unexpected end of data")
+	}
+
+	out := make([]T, 0, l)
+	for uint64(len(out)) < l {
+		var elem T
+
+		consumed, err := unmarshalValue(data[n:], reflect.ValueOf(&elem).Elem())
+		if err != nil {
+			return fmt.Errorf("element %d: %w", len(out), err)
+		}
+
+		out = append(out, elem)
+		n += consumed
+	}
+
+	*s = out
+	return nil
+}
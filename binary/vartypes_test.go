@@ -0,0 +1,60 @@
+package binary
+
+import "testing"
+
+// A 0xff VarInt prefix followed by a length >= 2^63 must not crash the
+// decoder: naively comparing len(data) < n+int(l) lets the int(l)
+// conversion wrap negative and pass the bounds check, panicking on the
+// subsequent slice.
+func maxUint64VarIntPrefix() []byte {
+	return []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+}
+
+func TestVarStrUnmarshalRejectsOverflowingLength(t *testing.T) {
+	var s VarStr
+	if err := s.UnmarshalBinary(maxUint64VarIntPrefix()); err == nil {
+		t.Fatal("expected an error for an out-of-range VarStr length, got nil")
+	}
+}
+
+func TestUnmarshalRejectsOverflowingVarStrTag(t *testing.T) {
+	type withVarStr struct {
+		Name string `bin:"varstr"`
+	}
+
+	var v withVarStr
+	if err := Unmarshal(maxUint64VarIntPrefix(), &v); err == nil {
+		t.Fatal("expected an error for an out-of-range bin:\"varstr\" length, got nil")
+	}
+}
+
+// A VarSlice whose VarInt element count is both far larger than the bytes
+// actually available, and large enough to overflow a naive allocation,
+// must be rejected rather than panicking in make().
+func TestVarSliceUnmarshalRejectsOversizedCount(t *testing.T) {
+	var s VarSlice[InvVect]
+	if err := s.UnmarshalBinary(maxUint64VarIntPrefix()); err == nil {
+		t.Fatal("expected an error for an out-of-range VarSlice element count, got nil")
+	}
+}
+
+func TestVarSliceRoundTrip(t *testing.T) {
+	want := VarSlice[InvVect]{
+		{Type: 1, Hash: [32]byte{1}},
+		{Type: 2, Hash: [32]byte{2}},
+	}
+
+	b, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	var got VarSlice[InvVect]
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}